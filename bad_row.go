@@ -0,0 +1,97 @@
+package snowplow
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LoaderParsingErrorSchema is the self-describing schema BadRow is wrapped
+// in, modeled on the Snowplow bad_rows schema family.
+const LoaderParsingErrorSchema = "iglu:com.snowplowanalytics.snowplow.badrows/loader_parsing_error/jsonschema/2-0-0"
+
+// BadRowProcessorArtifact identifies this SDK as the processor that
+// produced a BadRow.
+const BadRowProcessorArtifact = "snowplow-go-analytics-sdk"
+
+// FailureMessage describes one failure recorded in a BadRow. Field is the
+// TSV column that could not be converted, "*" for a failure of the whole
+// line, or the Iglu URI of a payload that failed schema validation.
+type FailureMessage struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+// BadRow is a structured record of a failed Transform, retaining the raw
+// line so nothing is lost on the first bad field.
+type BadRow struct {
+	Processor struct {
+		Artifact string `json:"artifact"`
+	} `json:"processor"`
+	Failure struct {
+		Timestamp time.Time        `json:"timestamp"`
+		Messages  []FailureMessage `json:"messages"`
+	} `json:"failure"`
+	Payload struct {
+		Raw string `json:"raw"`
+	} `json:"payload"`
+}
+
+// newBadRow builds a BadRow for the given raw line and per-field failures.
+func newBadRow(line string, messages []FailureMessage) *BadRow {
+	br := &BadRow{}
+	br.Processor.Artifact = BadRowProcessorArtifact
+	br.Failure.Timestamp = time.Now().UTC()
+	br.Failure.Messages = messages
+	br.Payload.Raw = base64.StdEncoding.EncodeToString([]byte(line))
+	return br
+}
+
+// badRowData is a plain alias of BadRow's fields used to avoid recursing
+// back into MarshalJSON while building the envelope below.
+type badRowData BadRow
+
+// MarshalJSON wraps the BadRow in the standard self-describing envelope
+// loaders expect.
+func (br *BadRow) MarshalJSON() ([]byte, error) {
+	type envelope struct {
+		Schema string      `json:"schema"`
+		Data   *badRowData `json:"data"`
+	}
+	return json.Marshal(envelope{Schema: LoaderParsingErrorSchema, Data: (*badRowData)(br)})
+}
+
+// TransformOrBadRow converts a Snowplow enriched event TSV into a typed
+// Event. On a field-count mismatch or any field-conversion failure it
+// returns a BadRow describing every failure instead of discarding the raw
+// line, so downstream loaders can process failures uniformly.
+func TransformOrBadRow(line string, knownFields [][]string) (*Event, *BadRow, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != len(knownFields) {
+		msg := FailureMessage{
+			Field: "*",
+			Value: line,
+			Error: fmt.Sprintf("expected %d fields, received %d fields", len(knownFields), len(fields)),
+		}
+		return nil, newBadRow(line, []FailureMessage{msg}), nil
+	}
+
+	e := &Event{}
+	var messages []FailureMessage
+	for i, t := range knownFields {
+		k, v := t[0], fields[i]
+		if v == "" {
+			continue
+		}
+		if err := assignField(e, k, v); err != nil {
+			messages = append(messages, FailureMessage{Field: k, Value: v, Error: err.Error()})
+		}
+	}
+	if len(messages) > 0 {
+		return nil, newBadRow(line, messages), nil
+	}
+	return e, nil, nil
+}
@@ -0,0 +1,84 @@
+package snowplow
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTransformOrBadRowFieldCountMismatch(t *testing.T) {
+	line := "only\tthree\tfields"
+	e, br, err := TransformOrBadRow(line, EnrichedEventFieldTypes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e != nil {
+		t.Fatalf("expected nil event, got %+v", e)
+	}
+	if br == nil {
+		t.Fatal("expected a BadRow, got nil")
+	}
+	if len(br.Failure.Messages) != 1 {
+		t.Fatalf("expected exactly one failure message, got %d: %+v", len(br.Failure.Messages), br.Failure.Messages)
+	}
+	if !strings.Contains(br.Failure.Messages[0].Error, "expected") {
+		t.Fatalf("failure message doesn't describe the count mismatch: %+v", br.Failure.Messages[0])
+	}
+	out, err := json.Marshal(br)
+	if err != nil {
+		t.Fatalf("marshal BadRow: %v", err)
+	}
+	if !strings.Contains(string(out), LoaderParsingErrorSchema) {
+		t.Fatalf("BadRow JSON missing envelope schema: %s", out)
+	}
+	if !strings.Contains(string(out), `"raw":"`) {
+		t.Fatalf("BadRow JSON missing base64-encoded raw payload: %s", out)
+	}
+}
+
+func TestTransformOrBadRowConversionFailure(t *testing.T) {
+	fields := make([]string, len(EnrichedEventFieldTypes))
+	for i, t := range EnrichedEventFieldTypes {
+		if t[0] == "txn_id" {
+			fields[i] = "not-an-int"
+		}
+	}
+	line := strings.Join(fields, "\t")
+
+	e, br, err := TransformOrBadRow(line, EnrichedEventFieldTypes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e != nil {
+		t.Fatalf("expected nil event, got %+v", e)
+	}
+	if br == nil {
+		t.Fatal("expected a BadRow, got nil")
+	}
+	if len(br.Failure.Messages) != 1 {
+		t.Fatalf("expected exactly one failure message, got %d: %+v", len(br.Failure.Messages), br.Failure.Messages)
+	}
+	if br.Failure.Messages[0].Field != "txn_id" {
+		t.Fatalf("failure message references the wrong field: %+v", br.Failure.Messages[0])
+	}
+	if br.Failure.Messages[0].Value != "not-an-int" {
+		t.Fatalf("failure message lost the original value: %+v", br.Failure.Messages[0])
+	}
+}
+
+func TestTransformOrBadRowSuccess(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+	})
+	e, br, err := TransformOrBadRow(line, EnrichedEventFieldTypes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if br != nil {
+		t.Fatalf("expected no BadRow, got %+v", br)
+	}
+	if e == nil || e.AppID != "test-app" {
+		t.Fatalf("expected a parsed event, got %+v", e)
+	}
+}
@@ -0,0 +1,1038 @@
+package snowplow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SelfDescribingData is a decoded Iglu self-describing JSON payload: the
+// original "iglu:vendor/name/format/model-revision-addition" URI alongside
+// its inner data. Contexts, derived_contexts and unstruct_event all shred
+// down to one or more values of this shape.
+type SelfDescribingData struct {
+	Schema string      `json:"schema"`
+	Data   interface{} `json:"data"`
+}
+
+// Event is a strongly-typed mirror of the Snowplow canonical event model,
+// covering every column in EnrichedEventFieldTypes. Columns that may be
+// blank in the enriched TSV use pointer types so a missing value can be
+// told apart from a real zero/false/"".
+type Event struct {
+	AppID                  string
+	Platform               string
+	EtlTstamp              *time.Time
+	CollectorTstamp        time.Time
+	DvceCreatedTstamp      *time.Time
+	Event                  string
+	EventID                string
+	TxnID                  *int64
+	NameTracker            string
+	VTracker               string
+	VCollector             string
+	VEtl                   string
+	UserID                 string
+	UserIpaddress          string
+	UserFingerprint        string
+	DomainUserid           string
+	DomainSessionidx       *int64
+	NetworkUserid          string
+	GeoCountry             string
+	GeoRegion              string
+	GeoCity                string
+	GeoZipcode             string
+	GeoLatitude            *float64
+	GeoLongitude           *float64
+	GeoRegionName          string
+	IPIsp                  string
+	IPOrganization         string
+	IPDomain               string
+	IPNetspeed             string
+	PageURL                string
+	PageTitle              string
+	PageReferrer           string
+	PageURLScheme          string
+	PageURLHost            string
+	PageURLPort            *int64
+	PageURLPath            string
+	PageURLQuery           string
+	PageURLFragment        string
+	RefrURLScheme          string
+	RefrURLHost            string
+	RefrURLPort            *int64
+	RefrURLPath            string
+	RefrURLQuery           string
+	RefrURLFragment        string
+	RefrMedium             string
+	RefrSource             string
+	RefrTerm               string
+	MktMedium              string
+	MktSource              string
+	MktTerm                string
+	MktContent             string
+	MktCampaign            string
+	Contexts               []SelfDescribingData
+	SeCategory             string
+	SeAction               string
+	SeLabel                string
+	SeProperty             string
+	SeValue                string
+	UnstructEvent          *SelfDescribingData
+	TrOrderid              string
+	TrAffiliation          string
+	TrTotal                *float64
+	TrTax                  *float64
+	TrShipping             *float64
+	TrCity                 string
+	TrState                string
+	TrCountry              string
+	TiOrderid              string
+	TiSku                  string
+	TiName                 string
+	TiCategory             string
+	TiPrice                *float64
+	TiQuantity             *int64
+	PpXoffsetMin           *int64
+	PpXoffsetMax           *int64
+	PpYoffsetMin           *int64
+	PpYoffsetMax           *int64
+	Useragent              string
+	BrName                 string
+	BrFamily               string
+	BrVersion              string
+	BrType                 string
+	BrRenderengine         string
+	BrLang                 string
+	BrFeaturesPdf          *bool
+	BrFeaturesFlash        *bool
+	BrFeaturesJava         *bool
+	BrFeaturesDirector     *bool
+	BrFeaturesQuicktime    *bool
+	BrFeaturesRealplayer   *bool
+	BrFeaturesWindowsmedia *bool
+	BrFeaturesGears        *bool
+	BrFeaturesSilverlight  *bool
+	BrCookies              *bool
+	BrColordepth           string
+	BrViewwidth            *int64
+	BrViewheight           *int64
+	OsName                 string
+	OsFamily               string
+	OsManufacturer         string
+	OsTimezone             string
+	DvceType               string
+	DvceIsMobile           *bool
+	DvceScreenwidth        *int64
+	DvceScreenheight       *int64
+	DocCharset             string
+	DocWidth               *int64
+	DocHeight              *int64
+	TrCurrency             string
+	TrTotalBase            *float64
+	TrTaxBase              *float64
+	TrShippingBase         *float64
+	TiCurrency             string
+	TiPriceBase            *float64
+	BaseCurrency           string
+	GeoTimezone            string
+	MktClickid             string
+	MktNetwork             string
+	EtlTags                string
+	DvceSentTstamp         *time.Time
+	RefrDomainUserid       string
+	RefrDeviceTstamp       *time.Time
+	DerivedContexts        []SelfDescribingData
+	DomainSessionid        string
+	DerivedTstamp          *time.Time
+	EventVendor            string
+	EventName              string
+	EventFormat            string
+	EventVersion           string
+	EventFingerprint       string
+	TrueTstamp             *time.Time
+
+	// GeoLocation mirrors the synthetic "geo_location" field Transform adds
+	// when addGeolocationData is set: "<geo_latitude>,<geo_longitude>".
+	GeoLocation *string
+
+	// Warnings holds non-fatal schema validation failures recorded by
+	// TransformWithResolver when it's configured to warn rather than
+	// reject on an invalid context or unstruct event.
+	Warnings []string
+}
+
+func parseInt64(v string) (*int64, error) {
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func parseFloat64(v string) (*float64, error) {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func parseBoolField(v string) *bool {
+	b := v == "1"
+	return &b
+}
+
+func parseTimestampField(v string) (*time.Time, error) {
+	t, err := time.Parse("2006-01-02 15:04:05.000", v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// TransformTyped converts a Snowplow enriched event TSV into a typed Event,
+// the strongly-typed counterpart to Transform.
+func TransformTyped(line string) (*Event, error) {
+	return jsonifyGoodEventTyped(strings.Split(line, "\t"), EnrichedEventFieldTypes, false)
+}
+
+// jsonifyGoodEventTyped is the typed counterpart of jsonifyGoodEvent: it
+// walks the same ordered field list but assigns straight into an Event
+// struct instead of a map, so callers get compile-time safety.
+func jsonifyGoodEventTyped(event []string, knownFields [][]string, addGeolocationData bool) (*Event, error) {
+	if len(event) != len(knownFields) {
+		return nil, fmt.Errorf("expected %d fields, received %d fields", len(knownFields), len(event))
+	}
+	e := &Event{}
+	errs := []string{}
+
+	if addGeolocationData && event[LatitudeIndex] != "" && event[LongitudeIndex] != "" {
+		loc := event[LatitudeIndex] + "," + event[LongitudeIndex]
+		e.GeoLocation = &loc
+	}
+
+	for i, t := range knownFields {
+		k, v := t[0], event[i]
+		if v == "" {
+			continue
+		}
+		if err := assignField(e, k, v); err != nil {
+			errs = append(errs, fmt.Sprintf("unexpected exception parsing field with key %s and value %s: %s", k, v, err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, ", "))
+	}
+	return e, nil
+}
+
+// assignField parses the raw TSV value for column k and stores it on e. It
+// covers the same columns as EnrichedEventFieldTypes.
+func assignField(e *Event, k, v string) error {
+	switch k {
+	case "app_id":
+		e.AppID = v
+	case "platform":
+		e.Platform = v
+	case "etl_tstamp":
+		t, err := parseTimestampField(v)
+		if err != nil {
+			return err
+		}
+		e.EtlTstamp = t
+	case "collector_tstamp":
+		t, err := parseTimestampField(v)
+		if err != nil {
+			return err
+		}
+		e.CollectorTstamp = *t
+	case "dvce_created_tstamp":
+		t, err := parseTimestampField(v)
+		if err != nil {
+			return err
+		}
+		e.DvceCreatedTstamp = t
+	case "event":
+		e.Event = v
+	case "event_id":
+		e.EventID = v
+	case "txn_id":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.TxnID = i
+	case "name_tracker":
+		e.NameTracker = v
+	case "v_tracker":
+		e.VTracker = v
+	case "v_collector":
+		e.VCollector = v
+	case "v_etl":
+		e.VEtl = v
+	case "user_id":
+		e.UserID = v
+	case "user_ipaddress":
+		e.UserIpaddress = v
+	case "user_fingerprint":
+		e.UserFingerprint = v
+	case "domain_userid":
+		e.DomainUserid = v
+	case "domain_sessionidx":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.DomainSessionidx = i
+	case "network_userid":
+		e.NetworkUserid = v
+	case "geo_country":
+		e.GeoCountry = v
+	case "geo_region":
+		e.GeoRegion = v
+	case "geo_city":
+		e.GeoCity = v
+	case "geo_zipcode":
+		e.GeoZipcode = v
+	case "geo_latitude":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.GeoLatitude = f
+	case "geo_longitude":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.GeoLongitude = f
+	case "geo_region_name":
+		e.GeoRegionName = v
+	case "ip_isp":
+		e.IPIsp = v
+	case "ip_organization":
+		e.IPOrganization = v
+	case "ip_domain":
+		e.IPDomain = v
+	case "ip_netspeed":
+		e.IPNetspeed = v
+	case "page_url":
+		e.PageURL = v
+	case "page_title":
+		e.PageTitle = v
+	case "page_referrer":
+		e.PageReferrer = v
+	case "page_urlscheme":
+		e.PageURLScheme = v
+	case "page_urlhost":
+		e.PageURLHost = v
+	case "page_urlport":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.PageURLPort = i
+	case "page_urlpath":
+		e.PageURLPath = v
+	case "page_urlquery":
+		e.PageURLQuery = v
+	case "page_urlfragment":
+		e.PageURLFragment = v
+	case "refr_urlscheme":
+		e.RefrURLScheme = v
+	case "refr_urlhost":
+		e.RefrURLHost = v
+	case "refr_urlport":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.RefrURLPort = i
+	case "refr_urlpath":
+		e.RefrURLPath = v
+	case "refr_urlquery":
+		e.RefrURLQuery = v
+	case "refr_urlfragment":
+		e.RefrURLFragment = v
+	case "refr_medium":
+		e.RefrMedium = v
+	case "refr_source":
+		e.RefrSource = v
+	case "refr_term":
+		e.RefrTerm = v
+	case "mkt_medium":
+		e.MktMedium = v
+	case "mkt_source":
+		e.MktSource = v
+	case "mkt_term":
+		e.MktTerm = v
+	case "mkt_content":
+		e.MktContent = v
+	case "mkt_campaign":
+		e.MktCampaign = v
+	case "contexts":
+		cs, err := parseContextsTyped([]byte(v))
+		if err != nil {
+			return err
+		}
+		e.Contexts = cs
+	case "se_category":
+		e.SeCategory = v
+	case "se_action":
+		e.SeAction = v
+	case "se_label":
+		e.SeLabel = v
+	case "se_property":
+		e.SeProperty = v
+	case "se_value":
+		e.SeValue = v
+	case "unstruct_event":
+		u, err := parseUnstructTyped([]byte(v))
+		if err != nil {
+			return err
+		}
+		e.UnstructEvent = u
+	case "tr_orderid":
+		e.TrOrderid = v
+	case "tr_affiliation":
+		e.TrAffiliation = v
+	case "tr_total":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.TrTotal = f
+	case "tr_tax":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.TrTax = f
+	case "tr_shipping":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.TrShipping = f
+	case "tr_city":
+		e.TrCity = v
+	case "tr_state":
+		e.TrState = v
+	case "tr_country":
+		e.TrCountry = v
+	case "ti_orderid":
+		e.TiOrderid = v
+	case "ti_sku":
+		e.TiSku = v
+	case "ti_name":
+		e.TiName = v
+	case "ti_category":
+		e.TiCategory = v
+	case "ti_price":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.TiPrice = f
+	case "ti_quantity":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.TiQuantity = i
+	case "pp_xoffset_min":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.PpXoffsetMin = i
+	case "pp_xoffset_max":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.PpXoffsetMax = i
+	case "pp_yoffset_min":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.PpYoffsetMin = i
+	case "pp_yoffset_max":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.PpYoffsetMax = i
+	case "useragent":
+		e.Useragent = v
+	case "br_name":
+		e.BrName = v
+	case "br_family":
+		e.BrFamily = v
+	case "br_version":
+		e.BrVersion = v
+	case "br_type":
+		e.BrType = v
+	case "br_renderengine":
+		e.BrRenderengine = v
+	case "br_lang":
+		e.BrLang = v
+	case "br_features_pdf":
+		e.BrFeaturesPdf = parseBoolField(v)
+	case "br_features_flash":
+		e.BrFeaturesFlash = parseBoolField(v)
+	case "br_features_java":
+		e.BrFeaturesJava = parseBoolField(v)
+	case "br_features_director":
+		e.BrFeaturesDirector = parseBoolField(v)
+	case "br_features_quicktime":
+		e.BrFeaturesQuicktime = parseBoolField(v)
+	case "br_features_realplayer":
+		e.BrFeaturesRealplayer = parseBoolField(v)
+	case "br_features_windowsmedia":
+		e.BrFeaturesWindowsmedia = parseBoolField(v)
+	case "br_features_gears":
+		e.BrFeaturesGears = parseBoolField(v)
+	case "br_features_silverlight":
+		e.BrFeaturesSilverlight = parseBoolField(v)
+	case "br_cookies":
+		e.BrCookies = parseBoolField(v)
+	case "br_colordepth":
+		e.BrColordepth = v
+	case "br_viewwidth":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.BrViewwidth = i
+	case "br_viewheight":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.BrViewheight = i
+	case "os_name":
+		e.OsName = v
+	case "os_family":
+		e.OsFamily = v
+	case "os_manufacturer":
+		e.OsManufacturer = v
+	case "os_timezone":
+		e.OsTimezone = v
+	case "dvce_type":
+		e.DvceType = v
+	case "dvce_ismobile":
+		e.DvceIsMobile = parseBoolField(v)
+	case "dvce_screenwidth":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.DvceScreenwidth = i
+	case "dvce_screenheight":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.DvceScreenheight = i
+	case "doc_charset":
+		e.DocCharset = v
+	case "doc_width":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.DocWidth = i
+	case "doc_height":
+		i, err := parseInt64(v)
+		if err != nil {
+			return err
+		}
+		e.DocHeight = i
+	case "tr_currency":
+		e.TrCurrency = v
+	case "tr_total_base":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.TrTotalBase = f
+	case "tr_tax_base":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.TrTaxBase = f
+	case "tr_shipping_base":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.TrShippingBase = f
+	case "ti_currency":
+		e.TiCurrency = v
+	case "ti_price_base":
+		f, err := parseFloat64(v)
+		if err != nil {
+			return err
+		}
+		e.TiPriceBase = f
+	case "base_currency":
+		e.BaseCurrency = v
+	case "geo_timezone":
+		e.GeoTimezone = v
+	case "mkt_clickid":
+		e.MktClickid = v
+	case "mkt_network":
+		e.MktNetwork = v
+	case "etl_tags":
+		e.EtlTags = v
+	case "dvce_sent_tstamp":
+		t, err := parseTimestampField(v)
+		if err != nil {
+			return err
+		}
+		e.DvceSentTstamp = t
+	case "refr_domain_userid":
+		e.RefrDomainUserid = v
+	case "refr_device_tstamp":
+		t, err := parseTimestampField(v)
+		if err != nil {
+			return err
+		}
+		e.RefrDeviceTstamp = t
+	case "derived_contexts":
+		cs, err := parseContextsTyped([]byte(v))
+		if err != nil {
+			return err
+		}
+		e.DerivedContexts = cs
+	case "domain_sessionid":
+		e.DomainSessionid = v
+	case "derived_tstamp":
+		t, err := parseTimestampField(v)
+		if err != nil {
+			return err
+		}
+		e.DerivedTstamp = t
+	case "event_vendor":
+		e.EventVendor = v
+	case "event_name":
+		e.EventName = v
+	case "event_format":
+		e.EventFormat = v
+	case "event_version":
+		e.EventVersion = v
+	case "event_fingerprint":
+		e.EventFingerprint = v
+	case "true_tstamp":
+		t, err := parseTimestampField(v)
+		if err != nil {
+			return err
+		}
+		e.TrueTstamp = t
+	}
+	return nil
+}
+
+// ToMap renders the Event back into the same map[string]interface{} shape
+// Transform returns, including shredded context/unstruct keys, for callers
+// migrating incrementally off the untyped API.
+func (e *Event) ToMap() (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	if e.GeoLocation != nil {
+		out["geo_location"] = *e.GeoLocation
+	}
+	for _, t := range EnrichedEventFieldTypes {
+		k := t[0]
+		v, ok, err := e.fieldValue(k)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		switch k {
+		case "contexts", "derived_contexts":
+			grouped, err := groupSelfDescribing(v.([]SelfDescribingData))
+			if err != nil {
+				return nil, err
+			}
+			for shredKey, data := range grouped {
+				out[shredKey] = data
+			}
+		case "unstruct_event":
+			sd := v.(SelfDescribingData)
+			shredKey, err := fixSchema("unstruct_event", sd.Schema)
+			if err != nil {
+				return nil, err
+			}
+			out[shredKey] = sd.Data
+		default:
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// fieldValue returns the current value for TSV column k along with whether
+// it is set (a nil pointer / empty string / empty slice counts as unset,
+// matching the way jsonifyGoodEvent omits blank TSV cells from the map).
+func (e *Event) fieldValue(k string) (interface{}, bool, error) {
+	switch k {
+	case "app_id":
+		return e.AppID, e.AppID != "", nil
+	case "platform":
+		return e.Platform, e.Platform != "", nil
+	case "etl_tstamp":
+		return derefTime(e.EtlTstamp)
+	case "collector_tstamp":
+		return e.CollectorTstamp, !e.CollectorTstamp.IsZero(), nil
+	case "dvce_created_tstamp":
+		return derefTime(e.DvceCreatedTstamp)
+	case "event":
+		return e.Event, e.Event != "", nil
+	case "event_id":
+		return e.EventID, e.EventID != "", nil
+	case "txn_id":
+		return derefInt(e.TxnID)
+	case "name_tracker":
+		return e.NameTracker, e.NameTracker != "", nil
+	case "v_tracker":
+		return e.VTracker, e.VTracker != "", nil
+	case "v_collector":
+		return e.VCollector, e.VCollector != "", nil
+	case "v_etl":
+		return e.VEtl, e.VEtl != "", nil
+	case "user_id":
+		return e.UserID, e.UserID != "", nil
+	case "user_ipaddress":
+		return e.UserIpaddress, e.UserIpaddress != "", nil
+	case "user_fingerprint":
+		return e.UserFingerprint, e.UserFingerprint != "", nil
+	case "domain_userid":
+		return e.DomainUserid, e.DomainUserid != "", nil
+	case "domain_sessionidx":
+		return derefInt(e.DomainSessionidx)
+	case "network_userid":
+		return e.NetworkUserid, e.NetworkUserid != "", nil
+	case "geo_country":
+		return e.GeoCountry, e.GeoCountry != "", nil
+	case "geo_region":
+		return e.GeoRegion, e.GeoRegion != "", nil
+	case "geo_city":
+		return e.GeoCity, e.GeoCity != "", nil
+	case "geo_zipcode":
+		return e.GeoZipcode, e.GeoZipcode != "", nil
+	case "geo_latitude":
+		return derefFloat(e.GeoLatitude)
+	case "geo_longitude":
+		return derefFloat(e.GeoLongitude)
+	case "geo_region_name":
+		return e.GeoRegionName, e.GeoRegionName != "", nil
+	case "ip_isp":
+		return e.IPIsp, e.IPIsp != "", nil
+	case "ip_organization":
+		return e.IPOrganization, e.IPOrganization != "", nil
+	case "ip_domain":
+		return e.IPDomain, e.IPDomain != "", nil
+	case "ip_netspeed":
+		return e.IPNetspeed, e.IPNetspeed != "", nil
+	case "page_url":
+		return e.PageURL, e.PageURL != "", nil
+	case "page_title":
+		return e.PageTitle, e.PageTitle != "", nil
+	case "page_referrer":
+		return e.PageReferrer, e.PageReferrer != "", nil
+	case "page_urlscheme":
+		return e.PageURLScheme, e.PageURLScheme != "", nil
+	case "page_urlhost":
+		return e.PageURLHost, e.PageURLHost != "", nil
+	case "page_urlport":
+		return derefInt(e.PageURLPort)
+	case "page_urlpath":
+		return e.PageURLPath, e.PageURLPath != "", nil
+	case "page_urlquery":
+		return e.PageURLQuery, e.PageURLQuery != "", nil
+	case "page_urlfragment":
+		return e.PageURLFragment, e.PageURLFragment != "", nil
+	case "refr_urlscheme":
+		return e.RefrURLScheme, e.RefrURLScheme != "", nil
+	case "refr_urlhost":
+		return e.RefrURLHost, e.RefrURLHost != "", nil
+	case "refr_urlport":
+		return derefInt(e.RefrURLPort)
+	case "refr_urlpath":
+		return e.RefrURLPath, e.RefrURLPath != "", nil
+	case "refr_urlquery":
+		return e.RefrURLQuery, e.RefrURLQuery != "", nil
+	case "refr_urlfragment":
+		return e.RefrURLFragment, e.RefrURLFragment != "", nil
+	case "refr_medium":
+		return e.RefrMedium, e.RefrMedium != "", nil
+	case "refr_source":
+		return e.RefrSource, e.RefrSource != "", nil
+	case "refr_term":
+		return e.RefrTerm, e.RefrTerm != "", nil
+	case "mkt_medium":
+		return e.MktMedium, e.MktMedium != "", nil
+	case "mkt_source":
+		return e.MktSource, e.MktSource != "", nil
+	case "mkt_term":
+		return e.MktTerm, e.MktTerm != "", nil
+	case "mkt_content":
+		return e.MktContent, e.MktContent != "", nil
+	case "mkt_campaign":
+		return e.MktCampaign, e.MktCampaign != "", nil
+	case "contexts":
+		return e.Contexts, len(e.Contexts) > 0, nil
+	case "se_category":
+		return e.SeCategory, e.SeCategory != "", nil
+	case "se_action":
+		return e.SeAction, e.SeAction != "", nil
+	case "se_label":
+		return e.SeLabel, e.SeLabel != "", nil
+	case "se_property":
+		return e.SeProperty, e.SeProperty != "", nil
+	case "se_value":
+		return e.SeValue, e.SeValue != "", nil
+	case "unstruct_event":
+		if e.UnstructEvent == nil {
+			return nil, false, nil
+		}
+		return *e.UnstructEvent, true, nil
+	case "tr_orderid":
+		return e.TrOrderid, e.TrOrderid != "", nil
+	case "tr_affiliation":
+		return e.TrAffiliation, e.TrAffiliation != "", nil
+	case "tr_total":
+		return derefFloat(e.TrTotal)
+	case "tr_tax":
+		return derefFloat(e.TrTax)
+	case "tr_shipping":
+		return derefFloat(e.TrShipping)
+	case "tr_city":
+		return e.TrCity, e.TrCity != "", nil
+	case "tr_state":
+		return e.TrState, e.TrState != "", nil
+	case "tr_country":
+		return e.TrCountry, e.TrCountry != "", nil
+	case "ti_orderid":
+		return e.TiOrderid, e.TiOrderid != "", nil
+	case "ti_sku":
+		return e.TiSku, e.TiSku != "", nil
+	case "ti_name":
+		return e.TiName, e.TiName != "", nil
+	case "ti_category":
+		return e.TiCategory, e.TiCategory != "", nil
+	case "ti_price":
+		return derefFloat(e.TiPrice)
+	case "ti_quantity":
+		return derefInt(e.TiQuantity)
+	case "pp_xoffset_min":
+		return derefInt(e.PpXoffsetMin)
+	case "pp_xoffset_max":
+		return derefInt(e.PpXoffsetMax)
+	case "pp_yoffset_min":
+		return derefInt(e.PpYoffsetMin)
+	case "pp_yoffset_max":
+		return derefInt(e.PpYoffsetMax)
+	case "useragent":
+		return e.Useragent, e.Useragent != "", nil
+	case "br_name":
+		return e.BrName, e.BrName != "", nil
+	case "br_family":
+		return e.BrFamily, e.BrFamily != "", nil
+	case "br_version":
+		return e.BrVersion, e.BrVersion != "", nil
+	case "br_type":
+		return e.BrType, e.BrType != "", nil
+	case "br_renderengine":
+		return e.BrRenderengine, e.BrRenderengine != "", nil
+	case "br_lang":
+		return e.BrLang, e.BrLang != "", nil
+	case "br_features_pdf":
+		return derefBool(e.BrFeaturesPdf)
+	case "br_features_flash":
+		return derefBool(e.BrFeaturesFlash)
+	case "br_features_java":
+		return derefBool(e.BrFeaturesJava)
+	case "br_features_director":
+		return derefBool(e.BrFeaturesDirector)
+	case "br_features_quicktime":
+		return derefBool(e.BrFeaturesQuicktime)
+	case "br_features_realplayer":
+		return derefBool(e.BrFeaturesRealplayer)
+	case "br_features_windowsmedia":
+		return derefBool(e.BrFeaturesWindowsmedia)
+	case "br_features_gears":
+		return derefBool(e.BrFeaturesGears)
+	case "br_features_silverlight":
+		return derefBool(e.BrFeaturesSilverlight)
+	case "br_cookies":
+		return derefBool(e.BrCookies)
+	case "br_colordepth":
+		return e.BrColordepth, e.BrColordepth != "", nil
+	case "br_viewwidth":
+		return derefInt(e.BrViewwidth)
+	case "br_viewheight":
+		return derefInt(e.BrViewheight)
+	case "os_name":
+		return e.OsName, e.OsName != "", nil
+	case "os_family":
+		return e.OsFamily, e.OsFamily != "", nil
+	case "os_manufacturer":
+		return e.OsManufacturer, e.OsManufacturer != "", nil
+	case "os_timezone":
+		return e.OsTimezone, e.OsTimezone != "", nil
+	case "dvce_type":
+		return e.DvceType, e.DvceType != "", nil
+	case "dvce_ismobile":
+		return derefBool(e.DvceIsMobile)
+	case "dvce_screenwidth":
+		return derefInt(e.DvceScreenwidth)
+	case "dvce_screenheight":
+		return derefInt(e.DvceScreenheight)
+	case "doc_charset":
+		return e.DocCharset, e.DocCharset != "", nil
+	case "doc_width":
+		return derefInt(e.DocWidth)
+	case "doc_height":
+		return derefInt(e.DocHeight)
+	case "tr_currency":
+		return e.TrCurrency, e.TrCurrency != "", nil
+	case "tr_total_base":
+		return derefFloat(e.TrTotalBase)
+	case "tr_tax_base":
+		return derefFloat(e.TrTaxBase)
+	case "tr_shipping_base":
+		return derefFloat(e.TrShippingBase)
+	case "ti_currency":
+		return e.TiCurrency, e.TiCurrency != "", nil
+	case "ti_price_base":
+		return derefFloat(e.TiPriceBase)
+	case "base_currency":
+		return e.BaseCurrency, e.BaseCurrency != "", nil
+	case "geo_timezone":
+		return e.GeoTimezone, e.GeoTimezone != "", nil
+	case "mkt_clickid":
+		return e.MktClickid, e.MktClickid != "", nil
+	case "mkt_network":
+		return e.MktNetwork, e.MktNetwork != "", nil
+	case "etl_tags":
+		return e.EtlTags, e.EtlTags != "", nil
+	case "dvce_sent_tstamp":
+		return derefTime(e.DvceSentTstamp)
+	case "refr_domain_userid":
+		return e.RefrDomainUserid, e.RefrDomainUserid != "", nil
+	case "refr_device_tstamp":
+		return derefTime(e.RefrDeviceTstamp)
+	case "derived_contexts":
+		return e.DerivedContexts, len(e.DerivedContexts) > 0, nil
+	case "domain_sessionid":
+		return e.DomainSessionid, e.DomainSessionid != "", nil
+	case "derived_tstamp":
+		return derefTime(e.DerivedTstamp)
+	case "event_vendor":
+		return e.EventVendor, e.EventVendor != "", nil
+	case "event_name":
+		return e.EventName, e.EventName != "", nil
+	case "event_format":
+		return e.EventFormat, e.EventFormat != "", nil
+	case "event_version":
+		return e.EventVersion, e.EventVersion != "", nil
+	case "event_fingerprint":
+		return e.EventFingerprint, e.EventFingerprint != "", nil
+	case "true_tstamp":
+		return derefTime(e.TrueTstamp)
+	}
+	return nil, false, nil
+}
+
+func derefInt(p *int64) (interface{}, bool, error) {
+	if p == nil {
+		return nil, false, nil
+	}
+	return *p, true, nil
+}
+
+func derefFloat(p *float64) (interface{}, bool, error) {
+	if p == nil {
+		return nil, false, nil
+	}
+	return *p, true, nil
+}
+
+func derefBool(p *bool) (interface{}, bool, error) {
+	if p == nil {
+		return nil, false, nil
+	}
+	return *p, true, nil
+}
+
+func derefTime(p *time.Time) (interface{}, bool, error) {
+	if p == nil {
+		return nil, false, nil
+	}
+	return *p, true, nil
+}
+
+// groupSelfDescribing shreds a list of self-describing payloads into the
+// Elasticsearch-style field-name grouping fixSchema produces, e.g.
+// "contexts_com_acme_ad_2" -> [data, data, ...].
+func groupSelfDescribing(entries []SelfDescribingData) (map[string][]interface{}, error) {
+	out := map[string][]interface{}{}
+	for _, sd := range entries {
+		shredKey, err := fixSchema("contexts", sd.Schema)
+		if err != nil {
+			return nil, err
+		}
+		out[shredKey] = append(out[shredKey], sd.Data)
+	}
+	return out, nil
+}
+
+// parseContextsTyped decodes a contexts/derived_contexts JSON payload into
+// SelfDescribingData values, retaining the full Iglu URI of each entry
+// (unlike parseContexts, which only keeps the shredded field name).
+func parseContextsTyped(data []byte) ([]SelfDescribingData, error) {
+	var contexts Contexts
+	if err := json.Unmarshal(data, &contexts); err != nil {
+		return nil, err
+	}
+	out := make([]SelfDescribingData, 0, len(contexts.Data))
+	for _, c := range contexts.Data {
+		out = append(out, SelfDescribingData{Schema: c.Schema, Data: c.Data})
+	}
+	return out, nil
+}
+
+// parseUnstructTyped decodes an unstruct_event JSON payload into a
+// SelfDescribingData, retaining the full Iglu URI.
+func parseUnstructTyped(data []byte) (*SelfDescribingData, error) {
+	var unstruct Unstruct
+	if err := json.Unmarshal(data, &unstruct); err != nil {
+		return nil, err
+	}
+	if unstruct.Data.Data == nil {
+		return nil, errors.New("could not extract inner data field from unstructured event")
+	}
+	return &SelfDescribingData{Schema: unstruct.Data.Schema, Data: unstruct.Data.Data}, nil
+}
+
+// MarshalJSON renders the Event using the same shredded key names
+// (unstruct_event_com_acme_click_1, contexts_com_acme_ad_2) that Transform
+// produces, so an *Event can be dropped in anywhere a JSON-encoded good
+// event is expected.
+func (e *Event) MarshalJSON() ([]byte, error) {
+	m, err := e.ToMap()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
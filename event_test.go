@@ -0,0 +1,73 @@
+package snowplow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransformTypedToMapRoundTrip(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"platform":         "web",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"event_id":         "event-id-1",
+		"geo_latitude":     "40.7128",
+		"geo_longitude":    "-74.0060",
+		"unstruct_event":   testUnstructJSON,
+		"contexts":         testContextsJSON,
+	})
+
+	typed, err := TransformTyped(line)
+	if err != nil {
+		t.Fatalf("TransformTyped: %v", err)
+	}
+
+	untyped, err := Transform(line, EnrichedEventFieldTypes, false)
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+
+	typedMap, err := typed.ToMap()
+	if err != nil {
+		t.Fatalf("ToMap: %v", err)
+	}
+
+	if typedMap["app_id"] != untyped["app_id"] {
+		t.Fatalf("app_id mismatch: got %v, want %v", typedMap["app_id"], untyped["app_id"])
+	}
+	if typedMap["event_id"] != untyped["event_id"] {
+		t.Fatalf("event_id mismatch: got %v, want %v", typedMap["event_id"], untyped["event_id"])
+	}
+	if typedMap["unstruct_event_com_acme_click_1"] == nil || untyped["unstruct_event_com_acme_click_1"] == nil {
+		t.Fatalf("shredded unstruct key missing: typed=%v untyped=%v", typedMap, untyped)
+	}
+	if typedMap["contexts_com_acme_ad_1"] == nil || untyped["contexts_com_acme_ad_1"] == nil {
+		t.Fatalf("shredded contexts key missing: typed=%v untyped=%v", typedMap, untyped)
+	}
+	if _, ok := typedMap["geo_location"]; ok {
+		t.Fatalf("geo_location should not be present when addGeolocationData is false: %v", typedMap)
+	}
+}
+
+func TestTransformTypedMarshalJSON(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"unstruct_event":   testUnstructJSON,
+	})
+
+	e, err := TransformTyped(line)
+	if err != nil {
+		t.Fatalf("TransformTyped: %v", err)
+	}
+	out, err := e.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	if !strings.Contains(string(out), `"app_id":"test-app"`) {
+		t.Fatalf("missing app_id in marshaled JSON: %s", out)
+	}
+	if !strings.Contains(string(out), "unstruct_event_com_acme_click_1") {
+		t.Fatalf("missing shredded unstruct key in marshaled JSON: %s", out)
+	}
+}
@@ -0,0 +1,108 @@
+package snowplow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TransformWithFields converts a Snowplow enriched event TSV into a map,
+// like Transform, but only converts the columns named in wanted. Columns
+// that aren't requested never reach converters[...], so contexts and
+// unstruct_event are skipped entirely (no JSON unmarshalling) when callers
+// don't ask for them.
+func TransformWithFields(line string, knownFields [][]string, wanted []string, addGeolocationData bool) (map[string]interface{}, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != len(knownFields) {
+		return nil, fmt.Errorf("expected %d fields, received %d fields", len(knownFields), len(fields))
+	}
+
+	wantedSet := map[string]bool{}
+	for _, w := range wanted {
+		wantedSet[w] = true
+	}
+
+	out := map[string]interface{}{}
+	errs := []string{}
+	if addGeolocationData && wantedSet["geo_location"] && fields[LatitudeIndex] != "" && fields[LongitudeIndex] != "" {
+		out["geo_location"] = fields[LatitudeIndex] + "," + fields[LongitudeIndex]
+	}
+	for i, t := range knownFields {
+		k := t[0]
+		if !wantedSet[k] || fields[i] == "" {
+			continue
+		}
+		if ok, err := converters[t[1]](k, fields[i]); err != nil {
+			errs = append(errs, fmt.Sprintf("unexpected exception parsing field with key %s and value %s: %s", k, fields[i], err.Error()))
+		} else {
+			for _, v := range ok {
+				out[v[0].(string)] = v[1]
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, ", "))
+	}
+	return out, nil
+}
+
+// ContextsMatching decodes only the contexts/derived_contexts entries of an
+// enriched event TSV line whose Iglu URI matches schemaFilter
+// ("vendor/name", e.g. "com.acme/ad"), letting callers pull one context
+// without unmarshalling every entity in the array. Named ContextsMatching
+// rather than Contexts to avoid colliding with the existing Contexts
+// envelope type in json_shredder.go.
+func ContextsMatching(line string, schemaFilter string) ([]SelfDescribingData, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != len(EnrichedEventFieldTypes) {
+		return nil, fmt.Errorf("expected %d fields, received %d fields", len(EnrichedEventFieldTypes), len(fields))
+	}
+
+	var out []SelfDescribingData
+	for i, t := range EnrichedEventFieldTypes {
+		if t[0] != "contexts" && t[0] != "derived_contexts" {
+			continue
+		}
+		if fields[i] == "" {
+			continue
+		}
+		matched, err := contextsMatchingSchema([]byte(fields[i]), schemaFilter)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, matched...)
+	}
+	return out, nil
+}
+
+func contextsMatchingSchema(data []byte, schemaFilter string) ([]SelfDescribingData, error) {
+	// Entity data stays raw until its schema matches the filter.
+	var contexts struct {
+		Data []struct {
+			Schema string          `json:"schema"`
+			Data   json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &contexts); err != nil {
+		return nil, err
+	}
+	var out []SelfDescribingData
+	for _, c := range contexts.Data {
+		schema, err := extractSchema(c.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if fmt.Sprintf("%s/%s", schema.Vendor, schema.Name) != schemaFilter {
+			continue
+		}
+		var inner interface{}
+		if len(c.Data) > 0 {
+			if err := json.Unmarshal(c.Data, &inner); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, SelfDescribingData{Schema: c.Schema, Data: inner})
+	}
+	return out, nil
+}
@@ -0,0 +1,91 @@
+package snowplow
+
+import (
+	"testing"
+)
+
+func TestTransformWithFieldsOnlyConvertsWanted(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"platform":         "web",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"unstruct_event":   testUnstructJSON,
+		"contexts":         testContextsJSON,
+	})
+
+	out, err := TransformWithFields(line, EnrichedEventFieldTypes, []string{"app_id", "platform"}, false)
+	if err != nil {
+		t.Fatalf("TransformWithFields: %v", err)
+	}
+	if out["app_id"] != "test-app" {
+		t.Fatalf("expected app_id to be converted, got %+v", out)
+	}
+	if out["platform"] != "web" {
+		t.Fatalf("expected platform to be converted, got %+v", out)
+	}
+	if _, ok := out["collector_tstamp"]; ok {
+		t.Fatalf("collector_tstamp was not requested but is present: %+v", out)
+	}
+	if _, ok := out["unstruct_event_com_acme_click_1"]; ok {
+		t.Fatalf("unstruct_event was not requested but was unmarshalled: %+v", out)
+	}
+	if _, ok := out["contexts_com_acme_ad_1"]; ok {
+		t.Fatalf("contexts was not requested but was unmarshalled: %+v", out)
+	}
+}
+
+func TestTransformWithFieldsGeolocation(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"geo_latitude":     "40.7128",
+		"geo_longitude":    "-74.0060",
+	})
+
+	out, err := TransformWithFields(line, EnrichedEventFieldTypes, []string{"geo_location"}, true)
+	if err != nil {
+		t.Fatalf("TransformWithFields: %v", err)
+	}
+	if out["geo_location"] != "40.7128,-74.0060" {
+		t.Fatalf("expected geo_location to be combined, got %+v", out)
+	}
+	if _, ok := out["app_id"]; ok {
+		t.Fatalf("app_id was not requested but is present: %+v", out)
+	}
+}
+
+func TestTransformWithFieldsFieldCountMismatch(t *testing.T) {
+	if _, err := TransformWithFields("only\tthree\tfields", EnrichedEventFieldTypes, []string{"app_id"}, false); err == nil {
+		t.Fatal("expected an error for a field-count mismatch")
+	}
+}
+
+func TestContextsMatchingFiltersBySchema(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"contexts":         testContextsJSON,
+	})
+
+	matched, err := ContextsMatching(line, "com.acme/ad")
+	if err != nil {
+		t.Fatalf("ContextsMatching: %v", err)
+	}
+	if len(matched) != 1 || matched[0].Schema != "iglu:com.acme/ad/jsonschema/1-0-0" {
+		t.Fatalf("expected one matching context, got %+v", matched)
+	}
+
+	none, err := ContextsMatching(line, "com.acme/nope")
+	if err != nil {
+		t.Fatalf("ContextsMatching: %v", err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("expected no matches for an unrelated schema, got %+v", none)
+	}
+}
+
+func TestContextsMatchingFieldCountMismatch(t *testing.T) {
+	if _, err := ContextsMatching("only\tthree\tfields", "com.acme/ad"); err == nil {
+		t.Fatal("expected an error for a field-count mismatch")
+	}
+}
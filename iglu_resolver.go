@@ -0,0 +1,152 @@
+package snowplow
+
+import (
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IgluResolver looks up the raw JSON schema for a self-describing payload's
+// Iglu URI. TransformWithResolver uses it to validate contexts and
+// unstruct events before they're accepted.
+type IgluResolver interface {
+	Lookup(schemaURI string) (schema []byte, err error)
+}
+
+// IgluRepository is one entry of a resolver-config document: a schema
+// registry to query, in priority order (lower Priority is tried first).
+type IgluRepository struct {
+	Name     string
+	Priority int
+	URI      string
+}
+
+// ResolverConfig is a resolver-config document listing the repositories an
+// HTTPIgluResolver queries, mirroring the Iglu resolver config used by the
+// other Snowplow SDKs.
+type ResolverConfig struct {
+	Repositories []IgluRepository
+}
+
+// HTTPIgluResolver resolves schemas against a prioritized list of HTTP Iglu
+// registries, caching results in an in-memory LRU.
+type HTTPIgluResolver struct {
+	config ResolverConfig
+	client *http.Client
+	cache  *schemaLRU
+}
+
+// NewHTTPIgluResolver builds an HTTPIgluResolver from a resolver config,
+// caching up to cacheSize resolved schemas in memory.
+func NewHTTPIgluResolver(config ResolverConfig, cacheSize int) *HTTPIgluResolver {
+	return &HTTPIgluResolver{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		cache:  newSchemaLRU(cacheSize),
+	}
+}
+
+// Lookup fetches the schema for schemaURI from the first repository that
+// has it, trying repositories in priority order, and caches the result.
+func (r *HTTPIgluResolver) Lookup(schemaURI string) ([]byte, error) {
+	if cached, ok := r.cache.get(schemaURI); ok {
+		return cached, nil
+	}
+
+	schema, err := extractSchema(schemaURI)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]IgluRepository, len(r.config.Repositories))
+	copy(repos, r.config.Repositories)
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Priority < repos[j].Priority })
+
+	var lastErr error
+	for _, repo := range repos {
+		url := strings.TrimRight(repo.URI, "/") + fmt.Sprintf("/schemas/%s/%s/%s/%s",
+			schema.Vendor, schema.Name, schema.Format, schema.Version)
+		data, err := r.fetch(url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		r.cache.put(schemaURI, data)
+		return data, nil
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("schema %s not found in any configured Iglu repository: %w", schemaURI, lastErr)
+	}
+	return nil, fmt.Errorf("schema %s not found: no Iglu repositories configured", schemaURI)
+}
+
+func (r *HTTPIgluResolver) fetch(url string) ([]byte, error) {
+	resp, err := r.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("iglu registry returned status %d for %s", resp.StatusCode, url)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// schemaLRU is a small fixed-size, in-memory LRU cache keyed by schema URI.
+type schemaLRU struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type schemaLRUEntry struct {
+	key   string
+	value []byte
+}
+
+func newSchemaLRU(size int) *schemaLRU {
+	if size <= 0 {
+		size = 1
+	}
+	return &schemaLRU{
+		size:  size,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (c *schemaLRU) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*schemaLRUEntry).value, true
+}
+
+func (c *schemaLRU) put(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*schemaLRUEntry).value = value
+		c.ll.MoveToFront(el)
+		return
+	}
+	el := c.ll.PushFront(&schemaLRUEntry{key: key, value: value})
+	c.items[key] = el
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		if oldest != nil {
+			c.ll.Remove(oldest)
+			delete(c.items, oldest.Value.(*schemaLRUEntry).key)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package snowplow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTTPIgluResolverNoRepositoriesConfigured(t *testing.T) {
+	resolver := NewHTTPIgluResolver(ResolverConfig{}, 10)
+	_, err := resolver.Lookup("iglu:com.acme/click/jsonschema/1-0-0")
+	if err == nil {
+		t.Fatal("expected an error when no repositories are configured")
+	}
+	if strings.Contains(err.Error(), "%!w") {
+		t.Fatalf("nil lastErr leaked into the error message: %v", err)
+	}
+	if !strings.Contains(err.Error(), "no Iglu repositories configured") {
+		t.Fatalf("expected a plain not-configured message, got: %v", err)
+	}
+}
+
+func TestSchemaLRUEvictsOldest(t *testing.T) {
+	cache := newSchemaLRU(2)
+	cache.put("a", []byte("1"))
+	cache.put("b", []byte("2"))
+	cache.put("c", []byte("3"))
+
+	if _, ok := cache.get("a"); ok {
+		t.Fatal("expected the oldest entry to be evicted")
+	}
+	if v, ok := cache.get("b"); !ok || string(v) != "2" {
+		t.Fatalf("expected b to still be cached, got %s ok=%v", v, ok)
+	}
+	if v, ok := cache.get("c"); !ok || string(v) != "3" {
+		t.Fatalf("expected c to still be cached, got %s ok=%v", v, ok)
+	}
+}
+
+func TestSchemaLRUMoveToFrontOnGet(t *testing.T) {
+	cache := newSchemaLRU(2)
+	cache.put("a", []byte("1"))
+	cache.put("b", []byte("2"))
+	cache.get("a")
+	cache.put("c", []byte("3"))
+
+	if _, ok := cache.get("b"); ok {
+		t.Fatal("expected b to be evicted after a was touched more recently")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("expected a to survive since it was accessed most recently")
+	}
+}
@@ -33,7 +33,8 @@ type ContextsData struct {
 }
 
 type Unstruct struct {
-	Data UnstructData `json:"data"`
+	Schema string       `json:"schema"`
+	Data   UnstructData `json:"data"`
 }
 
 type UnstructData struct {
@@ -0,0 +1,16 @@
+// Package metrics defines the instrumentation hook Pipeline reports
+// through, so callers can wire it to Prometheus (or anything else) without
+// this SDK depending on a specific client library.
+package metrics
+
+// Recorder receives counters and latency observations from a Pipeline.
+// Implementations are expected to be safe for concurrent use, since a
+// Pipeline calls them from every worker goroutine.
+type Recorder interface {
+	// IncCounter increments the named counter by one, e.g. "events_ok" or
+	// "events_bad".
+	IncCounter(name string, labels map[string]string)
+	// ObserveHistogram records a single observation (in seconds) for the
+	// named histogram, e.g. "parse_latency".
+	ObserveHistogram(name string, seconds float64, labels map[string]string)
+}
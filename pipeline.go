@@ -0,0 +1,233 @@
+package snowplow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hiweb-io/snowplow-go-analytics-sdk/metrics"
+)
+
+// Sink receives the output of a Pipeline: good events, bad rows, and a
+// Flush signal once a batch should be delivered.
+type Sink interface {
+	Write(event map[string]interface{}) error
+	WriteBad(br *BadRow) error
+	Flush() error
+}
+
+// PipelineConfig configures a Pipeline.
+type PipelineConfig struct {
+	// Workers is the number of goroutines calling Transform concurrently.
+	// Defaults to 1.
+	Workers int
+	// KnownFields is passed straight through to Transform. Defaults to
+	// EnrichedEventFieldTypes.
+	KnownFields [][]string
+	// AddGeolocationData is passed straight through to Transform.
+	AddGeolocationData bool
+	// InputBufferSize bounds how many submitted lines may be queued before
+	// Submit blocks, providing backpressure. Defaults to 1000.
+	InputBufferSize int
+	// Sink receives transformed events and bad rows. May be nil to discard
+	// output (useful when only Metrics matters).
+	Sink Sink
+	// Metrics, if set, is notified of every processed event.
+	Metrics metrics.Recorder
+}
+
+// Pipeline wraps Transform in a worker pool so high-throughput consumers
+// (e.g. a Kinesis/Kafka reader) don't each have to hand-roll the same
+// worker loop.
+type Pipeline struct {
+	cfg       PipelineConfig
+	input     chan string
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewPipeline builds and starts a Pipeline's worker pool.
+func NewPipeline(cfg PipelineConfig) *Pipeline {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.InputBufferSize <= 0 {
+		cfg.InputBufferSize = 1000
+	}
+	if cfg.KnownFields == nil {
+		cfg.KnownFields = EnrichedEventFieldTypes
+	}
+
+	p := &Pipeline{
+		cfg:   cfg,
+		input: make(chan string, cfg.InputBufferSize),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p
+}
+
+// Submit enqueues a raw enriched event TSV line for processing. It blocks
+// once InputBufferSize lines are already queued.
+func (p *Pipeline) Submit(line string) {
+	p.input <- line
+}
+
+// Close stops accepting new lines, waits for in-flight work to finish, and
+// flushes the sink.
+func (p *Pipeline) Close() error {
+	p.closeOnce.Do(func() { close(p.input) })
+	p.wg.Wait()
+	if p.cfg.Sink != nil {
+		return p.cfg.Sink.Flush()
+	}
+	return nil
+}
+
+func (p *Pipeline) worker() {
+	defer p.wg.Done()
+	for line := range p.input {
+		start := time.Now()
+		event, err := Transform(line, p.cfg.KnownFields, p.cfg.AddGeolocationData)
+		if p.cfg.Metrics != nil {
+			p.cfg.Metrics.ObserveHistogram("parse_latency", time.Since(start).Seconds(), nil)
+		}
+		if err != nil {
+			if p.cfg.Metrics != nil {
+				p.cfg.Metrics.IncCounter("events_bad", nil)
+			}
+			if p.cfg.Sink != nil {
+				br := newBadRow(line, []FailureMessage{{Field: "*", Value: line, Error: err.Error()}})
+				if writeErr := p.cfg.Sink.WriteBad(br); writeErr != nil {
+					p.recordSinkError(writeErr)
+				}
+			}
+			continue
+		}
+		if p.cfg.Metrics != nil {
+			p.cfg.Metrics.IncCounter("events_ok", nil)
+		}
+		if p.cfg.Sink != nil {
+			if writeErr := p.cfg.Sink.Write(event); writeErr != nil {
+				p.recordSinkError(writeErr)
+			}
+		}
+	}
+}
+
+// recordSinkError counts a Sink.Write/WriteBad failure under "sink_errors"
+// when Metrics is set; without Metrics the error is discarded.
+// events_ok/events_bad were already incremented by the time a sink (e.g.
+// HTTPBulkSink flushing a full batch) can fail, so this is the only place
+// left to notice the loss.
+func (p *Pipeline) recordSinkError(err error) {
+	if p.cfg.Metrics != nil {
+		p.cfg.Metrics.IncCounter("sink_errors", nil)
+	}
+}
+
+// StdoutSink writes good events and bad rows as JSON lines to os.Stdout.
+type StdoutSink struct {
+	enc *json.Encoder
+}
+
+// NewStdoutSink builds a StdoutSink.
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{enc: json.NewEncoder(os.Stdout)}
+}
+
+func (s *StdoutSink) Write(event map[string]interface{}) error { return s.enc.Encode(event) }
+func (s *StdoutSink) WriteBad(br *BadRow) error                { return s.enc.Encode(br) }
+func (s *StdoutSink) Flush() error                             { return nil }
+
+// ChannelSink hands good events and bad rows off to Go channels, letting
+// callers consume a Pipeline's output with their own select loop.
+type ChannelSink struct {
+	Good chan map[string]interface{}
+	Bad  chan *BadRow
+}
+
+// NewChannelSink builds a ChannelSink with the given channel buffer size.
+func NewChannelSink(bufferSize int) *ChannelSink {
+	return &ChannelSink{
+		Good: make(chan map[string]interface{}, bufferSize),
+		Bad:  make(chan *BadRow, bufferSize),
+	}
+}
+
+func (s *ChannelSink) Write(event map[string]interface{}) error { s.Good <- event; return nil }
+func (s *ChannelSink) WriteBad(br *BadRow) error                { s.Bad <- br; return nil }
+
+// Flush closes both channels, signaling consumers that no more events are
+// coming.
+func (s *ChannelSink) Flush() error {
+	close(s.Good)
+	close(s.Bad)
+	return nil
+}
+
+// HTTPBulkSink batches good events and POSTs them as a JSON array once
+// BatchSize is reached or Flush is called. Bad rows are dropped; callers
+// who need to retain them should use a ChannelSink or StdoutSink instead.
+type HTTPBulkSink struct {
+	URL       string
+	BatchSize int
+
+	client *http.Client
+	mu     sync.Mutex
+	batch  []map[string]interface{}
+}
+
+// NewHTTPBulkSink builds an HTTPBulkSink that POSTs batches of batchSize
+// events to url.
+func NewHTTPBulkSink(url string, batchSize int) *HTTPBulkSink {
+	return &HTTPBulkSink{
+		URL:       url,
+		BatchSize: batchSize,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *HTTPBulkSink) Write(event map[string]interface{}) error {
+	s.mu.Lock()
+	s.batch = append(s.batch, event)
+	full := len(s.batch) >= s.BatchSize
+	s.mu.Unlock()
+	if full {
+		return s.Flush()
+	}
+	return nil
+}
+
+func (s *HTTPBulkSink) WriteBad(br *BadRow) error { return nil }
+
+// Flush POSTs any buffered events and clears the batch.
+func (s *HTTPBulkSink) Flush() error {
+	s.mu.Lock()
+	batch := s.batch
+	s.batch = nil
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("bulk sink received status %d from %s", resp.StatusCode, s.URL)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package snowplow
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/hiweb-io/snowplow-go-analytics-sdk/metrics"
+)
+
+type fakeSink struct {
+	mu       sync.Mutex
+	good     []map[string]interface{}
+	bad      []*BadRow
+	flushed  bool
+	writeErr error
+}
+
+func (s *fakeSink) Write(event map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+	s.good = append(s.good, event)
+	return nil
+}
+
+func (s *fakeSink) WriteBad(br *BadRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bad = append(s.bad, br)
+	return nil
+}
+
+func (s *fakeSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushed = true
+	return nil
+}
+
+type fakeRecorder struct {
+	mu           sync.Mutex
+	counters     map[string]int
+	observations int
+}
+
+func newFakeRecorder() *fakeRecorder {
+	return &fakeRecorder{counters: map[string]int{}}
+}
+
+func (r *fakeRecorder) IncCounter(name string, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[name]++
+}
+
+func (r *fakeRecorder) ObserveHistogram(name string, seconds float64, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observations++
+}
+
+var _ metrics.Recorder = (*fakeRecorder)(nil)
+
+func TestPipelineProcessesGoodAndBadLines(t *testing.T) {
+	sink := &fakeSink{}
+	rec := newFakeRecorder()
+	p := NewPipeline(PipelineConfig{
+		Workers: 2,
+		Sink:    sink,
+		Metrics: rec,
+	})
+
+	goodLine := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+	})
+	badLine := "only\tthree\tfields"
+
+	p.Submit(goodLine)
+	p.Submit(badLine)
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.good) != 1 {
+		t.Fatalf("expected 1 good event, got %d: %+v", len(sink.good), sink.good)
+	}
+	if sink.good[0]["app_id"] != "test-app" {
+		t.Fatalf("unexpected good event: %+v", sink.good[0])
+	}
+	if len(sink.bad) != 1 {
+		t.Fatalf("expected 1 bad row, got %d: %+v", len(sink.bad), sink.bad)
+	}
+	if !sink.flushed {
+		t.Fatal("expected Flush to be called on Close")
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.counters["events_ok"] != 1 {
+		t.Fatalf("expected events_ok=1, got %d", rec.counters["events_ok"])
+	}
+	if rec.counters["events_bad"] != 1 {
+		t.Fatalf("expected events_bad=1, got %d", rec.counters["events_bad"])
+	}
+	if rec.observations != 2 {
+		t.Fatalf("expected 2 latency observations, got %d", rec.observations)
+	}
+}
+
+func TestChannelSinkWriteAndFlush(t *testing.T) {
+	cs := NewChannelSink(2)
+	if err := cs.Write(map[string]interface{}{"app_id": "test-app"}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := cs.WriteBad(&BadRow{}); err != nil {
+		t.Fatalf("WriteBad: %v", err)
+	}
+	if err := cs.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	event, ok := <-cs.Good
+	if !ok || event["app_id"] != "test-app" {
+		t.Fatalf("expected buffered good event, got %+v ok=%v", event, ok)
+	}
+	if _, ok := <-cs.Bad; !ok {
+		t.Fatal("expected buffered bad row")
+	}
+}
+
+func TestPipelineRecordsSinkWriteErrors(t *testing.T) {
+	sink := &fakeSink{writeErr: errors.New("boom")}
+	rec := newFakeRecorder()
+	p := NewPipeline(PipelineConfig{
+		Workers: 1,
+		Sink:    sink,
+		Metrics: rec,
+	})
+
+	goodLine := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+	})
+	p.Submit(goodLine)
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.counters["events_ok"] != 1 {
+		t.Fatalf("expected events_ok=1, got %d", rec.counters["events_ok"])
+	}
+	if rec.counters["sink_errors"] != 1 {
+		t.Fatalf("expected sink_errors=1 to surface the dropped Write, got %d", rec.counters["sink_errors"])
+	}
+}
@@ -0,0 +1,104 @@
+package snowplow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaValidator validates a JSON instance against a draft-4/6/7 JSON
+// schema document. It's interface-typed so callers can plug in whichever
+// validator library (or none) they already depend on; this package ships
+// no implementation of its own.
+type SchemaValidator interface {
+	// Validate returns one error per schema violation, or an empty slice
+	// if instance conforms to schema.
+	Validate(schema []byte, instance interface{}) []error
+}
+
+// ValidationFailureMode controls what TransformWithResolver does when a
+// context or unstruct event fails schema validation.
+type ValidationFailureMode int
+
+const (
+	// ValidationFailureBadRow rejects the line, returning a BadRow.
+	ValidationFailureBadRow ValidationFailureMode = iota
+	// ValidationFailureWarning accepts the line, attaching the validation
+	// errors to Event.Warnings instead.
+	ValidationFailureWarning
+)
+
+// ValidationOptions controls how TransformWithResolver validates the
+// contexts and unstruct event of a line against their Iglu schemas.
+type ValidationOptions struct {
+	// Validator performs the actual schema check. If nil, no schema
+	// validation is attempted and TransformWithResolver returns the parsed
+	// Event without consulting the resolver.
+	Validator SchemaValidator
+	// FailFast stops at the first invalid self-describing payload instead
+	// of collecting every validation error across contexts/unstruct_event.
+	FailFast bool
+	// OnFailure chooses whether a validation failure produces a BadRow or
+	// is recorded as a warning on the returned Event.
+	OnFailure ValidationFailureMode
+}
+
+// TransformWithResolver converts a Snowplow enriched event TSV into a typed
+// Event, then validates every context and the unstruct event (if present)
+// against their Iglu schema via resolver. This turns the SDK from pure
+// parse into parse+validate, matching what production Snowplow loaders do
+// before writing to warehouses.
+func TransformWithResolver(line string, knownFields [][]string, resolver IgluResolver, opts ValidationOptions) (*Event, *BadRow, error) {
+	fields := strings.Split(line, "\t")
+	event, err := jsonifyGoodEventTyped(fields, knownFields, false)
+	if err != nil {
+		return nil, newBadRow(line, []FailureMessage{{Field: "*", Value: line, Error: err.Error()}}), nil
+	}
+
+	if opts.Validator == nil {
+		return event, nil, nil
+	}
+
+	var messages []FailureMessage
+	validate := func(sd SelfDescribingData) bool {
+		schema, err := resolver.Lookup(sd.Schema)
+		if err != nil {
+			messages = append(messages, FailureMessage{Field: sd.Schema, Value: fmt.Sprint(sd.Data), Error: err.Error()})
+			return opts.FailFast
+		}
+		for _, verr := range opts.Validator.Validate(schema, sd.Data) {
+			messages = append(messages, FailureMessage{Field: sd.Schema, Value: fmt.Sprint(sd.Data), Error: verr.Error()})
+			if opts.FailFast {
+				return true
+			}
+		}
+		return false
+	}
+
+	if event.UnstructEvent != nil {
+		if validate(*event.UnstructEvent) && opts.FailFast {
+			return finishValidation(line, event, messages, opts)
+		}
+	}
+	for _, sds := range [][]SelfDescribingData{event.Contexts, event.DerivedContexts} {
+		for _, sd := range sds {
+			if validate(sd) && opts.FailFast {
+				return finishValidation(line, event, messages, opts)
+			}
+		}
+	}
+
+	return finishValidation(line, event, messages, opts)
+}
+
+func finishValidation(line string, event *Event, messages []FailureMessage, opts ValidationOptions) (*Event, *BadRow, error) {
+	if len(messages) == 0 {
+		return event, nil, nil
+	}
+	if opts.OnFailure == ValidationFailureWarning {
+		for _, m := range messages {
+			event.Warnings = append(event.Warnings, fmt.Sprintf("%s: %s", m.Field, m.Error))
+		}
+		return event, nil, nil
+	}
+	return nil, newBadRow(line, messages), nil
+}
@@ -0,0 +1,164 @@
+package snowplow
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeResolver struct {
+	schemas map[string][]byte
+	err     error
+}
+
+func (r *fakeResolver) Lookup(schemaURI string) ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.schemas[schemaURI], nil
+}
+
+type fakeValidator struct {
+	// invalid lists the schema documents (as returned by the resolver)
+	// whose instances Validate should reject.
+	invalid map[string]bool
+}
+
+func (v *fakeValidator) Validate(schema []byte, instance interface{}) []error {
+	if v.invalid[string(schema)] {
+		return []error{errors.New("instance does not match schema")}
+	}
+	return nil
+}
+
+func TestTransformWithResolverNoValidator(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"unstruct_event":   testUnstructJSON,
+	})
+
+	event, br, err := TransformWithResolver(line, EnrichedEventFieldTypes, &fakeResolver{}, ValidationOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if br != nil {
+		t.Fatalf("expected no BadRow without a Validator, got %+v", br)
+	}
+	if event == nil || event.AppID != "test-app" {
+		t.Fatalf("expected a parsed event, got %+v", event)
+	}
+}
+
+func TestTransformWithResolverValidationFailureProducesBadRow(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"unstruct_event":   testUnstructJSON,
+		"contexts":         testContextsJSON,
+	})
+
+	resolver := &fakeResolver{schemas: map[string][]byte{
+		"iglu:com.acme/click/jsonschema/1-0-0": []byte("click-schema"),
+		"iglu:com.acme/ad/jsonschema/1-0-0":    []byte("ad-schema"),
+	}}
+	validator := &fakeValidator{invalid: map[string]bool{"click-schema": true, "ad-schema": true}}
+
+	event, br, err := TransformWithResolver(line, EnrichedEventFieldTypes, resolver, ValidationOptions{
+		Validator: validator,
+		OnFailure: ValidationFailureBadRow,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event != nil {
+		t.Fatalf("expected no event on validation failure, got %+v", event)
+	}
+	if br == nil {
+		t.Fatal("expected a BadRow")
+	}
+	if len(br.Failure.Messages) != 2 {
+		t.Fatalf("expected a failure message for both the unstruct event and the context, got %d: %+v", len(br.Failure.Messages), br.Failure.Messages)
+	}
+}
+
+func TestTransformWithResolverFailFastStopsAtFirstFailure(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"unstruct_event":   testUnstructJSON,
+		"contexts":         testContextsJSON,
+	})
+
+	resolver := &fakeResolver{schemas: map[string][]byte{
+		"iglu:com.acme/click/jsonschema/1-0-0": []byte("click-schema"),
+		"iglu:com.acme/ad/jsonschema/1-0-0":    []byte("ad-schema"),
+	}}
+	validator := &fakeValidator{invalid: map[string]bool{"click-schema": true, "ad-schema": true}}
+
+	_, br, err := TransformWithResolver(line, EnrichedEventFieldTypes, resolver, ValidationOptions{
+		Validator: validator,
+		FailFast:  true,
+		OnFailure: ValidationFailureBadRow,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if br == nil {
+		t.Fatal("expected a BadRow")
+	}
+	if len(br.Failure.Messages) != 1 {
+		t.Fatalf("expected FailFast to stop after the first failure, got %d: %+v", len(br.Failure.Messages), br.Failure.Messages)
+	}
+}
+
+func TestTransformWithResolverWarningModeKeepsEvent(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"unstruct_event":   testUnstructJSON,
+	})
+
+	resolver := &fakeResolver{schemas: map[string][]byte{
+		"iglu:com.acme/click/jsonschema/1-0-0": []byte("click-schema"),
+	}}
+	validator := &fakeValidator{invalid: map[string]bool{"click-schema": true}}
+
+	event, br, err := TransformWithResolver(line, EnrichedEventFieldTypes, resolver, ValidationOptions{
+		Validator: validator,
+		OnFailure: ValidationFailureWarning,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if br != nil {
+		t.Fatalf("expected no BadRow in warning mode, got %+v", br)
+	}
+	if event == nil || len(event.Warnings) != 1 {
+		t.Fatalf("expected one warning attached to the event, got %+v", event)
+	}
+}
+
+func TestTransformWithResolverResolverLookupError(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"unstruct_event":   testUnstructJSON,
+	})
+
+	resolver := &fakeResolver{err: errors.New("registry unreachable")}
+	validator := &fakeValidator{}
+
+	_, br, err := TransformWithResolver(line, EnrichedEventFieldTypes, resolver, ValidationOptions{
+		Validator: validator,
+		OnFailure: ValidationFailureBadRow,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if br == nil {
+		t.Fatal("expected a BadRow when the resolver fails to look up a schema")
+	}
+	if len(br.Failure.Messages) != 1 || br.Failure.Messages[0].Error != "registry unreachable" {
+		t.Fatalf("expected the resolver error to surface as a failure message, got %+v", br.Failure.Messages)
+	}
+}
@@ -0,0 +1,204 @@
+package snowplow
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContextsSchema is the canonical envelope schema Snowplow wraps a
+// contexts/derived_contexts array in.
+const ContextsSchema = "iglu:com.snowplowanalytics.snowplow/contexts/jsonschema/1-0-1"
+
+// UnstructEventSchema is the canonical envelope schema Snowplow wraps a
+// self-describing unstruct_event payload in.
+const UnstructEventSchema = "iglu:com.snowplowanalytics.snowplow/unstruct_event/jsonschema/1-0-0"
+
+// ToTSV re-serializes the Event back into a Snowplow enriched event TSV
+// line, the inverse of TransformTyped. Contexts and the unstruct event are
+// re-wrapped in their original Iglu self-describing envelope using the
+// schema URI retained on SelfDescribingData.
+func (e *Event) ToTSV() (string, error) {
+	fields := make([]string, len(EnrichedEventFieldTypes))
+	for i, t := range EnrichedEventFieldTypes {
+		s, err := e.tsvField(t[0])
+		if err != nil {
+			return "", err
+		}
+		fields[i] = s
+	}
+	return strings.Join(fields, "\t"), nil
+}
+
+// tsvField renders a single TSV column back to its wire-format string.
+func (e *Event) tsvField(k string) (string, error) {
+	v, ok, err := e.fieldValue(k)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", nil
+	}
+	switch k {
+	case "contexts":
+		return contextsToTSV(v.([]SelfDescribingData))
+	case "derived_contexts":
+		return contextsToTSV(v.([]SelfDescribingData))
+	case "unstruct_event":
+		return unstructToTSV(v.(SelfDescribingData))
+	}
+	return valueToTSV(v)
+}
+
+// valueToTSV renders a scalar field value using the same encodings
+// convertTimestamp/convertBool expect on the way in.
+func valueToTSV(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case bool:
+		if val {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05.000"), nil
+	default:
+		return "", fmt.Errorf("unsupported field type %T for TSV encoding", v)
+	}
+}
+
+func contextsToTSV(entries []SelfDescribingData) (string, error) {
+	data := make([]ContextsData, len(entries))
+	for i, sd := range entries {
+		data[i] = ContextsData{Schema: sd.Schema, Data: sd.Data}
+	}
+	out, err := json.Marshal(Contexts{Schema: ContextsSchema, Data: data})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func unstructToTSV(sd SelfDescribingData) (string, error) {
+	out, err := json.Marshal(Unstruct{
+		Schema: UnstructEventSchema,
+		Data:   UnstructData{Schema: sd.Schema, Data: sd.Data},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// ToTSVLine re-serializes a map produced by Transform back into a Snowplow
+// enriched event TSV line. Unlike ToTSV, it cannot recover the original
+// Iglu schema URI for contexts/unstruct_event: Transform's shredding only
+// keeps the snake-cased vendor/name/model, so the revision and addition
+// are not recoverable and are encoded as "0-0". The map form also doesn't
+// record which of "contexts"/"derived_contexts" a shredded "contexts_*"
+// key came from (fixSchema uses the same "contexts" prefix for both), so
+// every shredded context is emitted into the "contexts" column and
+// "derived_contexts" is left blank. Callers who need a lossless round
+// trip should keep working with the typed Event and call ToTSV instead.
+func ToTSVLine(event map[string]interface{}, knownFields [][]string) (string, error) {
+	fields := make([]string, len(knownFields))
+	for i, t := range knownFields {
+		k := t[0]
+		switch k {
+		case "contexts":
+			s, err := shreddedContextsToTSV(event)
+			if err != nil {
+				return "", err
+			}
+			fields[i] = s
+		case "derived_contexts":
+			// Ambiguous from the shredded map form; see doc comment above.
+		case "unstruct_event":
+			s, err := shreddedUnstructToTSV(event)
+			if err != nil {
+				return "", err
+			}
+			fields[i] = s
+		default:
+			v, ok := event[k]
+			if !ok {
+				continue
+			}
+			s, err := valueToTSV(v)
+			if err != nil {
+				return "", err
+			}
+			fields[i] = s
+		}
+	}
+	return strings.Join(fields, "\t"), nil
+}
+
+// shreddedContextsToTSV rebuilds a best-effort contexts envelope out of
+// every "contexts_*" key present in the map. Returns "" if none are
+// present, matching a blank TSV cell.
+func shreddedContextsToTSV(event map[string]interface{}) (string, error) {
+	data := []ContextsData{}
+	for k, v := range event {
+		if !strings.HasPrefix(k, "contexts_") {
+			continue
+		}
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+		for _, inner := range values {
+			data = append(data, ContextsData{Schema: guessSchemaFromShredKey("contexts", k), Data: inner})
+		}
+	}
+	if len(data) == 0 {
+		return "", nil
+	}
+	out, err := json.Marshal(Contexts{Schema: ContextsSchema, Data: data})
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func shreddedUnstructToTSV(event map[string]interface{}) (string, error) {
+	for k, v := range event {
+		if !strings.HasPrefix(k, "unstruct_event_") {
+			continue
+		}
+		out, err := json.Marshal(Unstruct{
+			Schema: UnstructEventSchema,
+			Data:   UnstructData{Schema: guessSchemaFromShredKey("unstruct_event", k), Data: v},
+		})
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	}
+	return "", nil
+}
+
+// guessSchemaFromShredKey approximates an Iglu URI from a fixSchema-style
+// key such as "contexts_com_acme_ad_2" given the known prefix ("contexts"
+// or "unstruct_event") it was built with. Vendor dots and multi-word name
+// casing were already lost during shredding, so this is lossy and only
+// useful as a best-effort fallback; a name with an underscore in it (e.g.
+// "click_event") cannot be told apart from a two-segment vendor.
+func guessSchemaFromShredKey(prefix, key string) string {
+	rest := strings.TrimPrefix(key, prefix+"_")
+	parts := strings.Split(rest, "_")
+	if len(parts) < 3 {
+		return key
+	}
+	model := parts[len(parts)-1]
+	name := parts[len(parts)-2]
+	vendor := strings.Join(parts[:len(parts)-2], ".")
+	return fmt.Sprintf("iglu:%s/%s/jsonschema/%s-0-0", vendor, name, model)
+}
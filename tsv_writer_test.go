@@ -0,0 +1,132 @@
+package snowplow
+
+import (
+	"strings"
+	"testing"
+)
+
+func buildEnrichedLine(overrides map[string]string) string {
+	fields := make([]string, len(EnrichedEventFieldTypes))
+	for i, t := range EnrichedEventFieldTypes {
+		fields[i] = overrides[t[0]]
+	}
+	return strings.Join(fields, "\t")
+}
+
+const testUnstructJSON = `{"data":{"schema":"iglu:com.acme/click/jsonschema/1-0-0","data":{"targetUrl":"http://example.com"}}}`
+const testContextsJSON = `{"data":[{"schema":"iglu:com.acme/ad/jsonschema/1-0-0","data":{"adId":"1"}}]}`
+
+func TestEventToTSVRoundTrip(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"platform":         "web",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"event_id":         "event-id-1",
+		"unstruct_event":   testUnstructJSON,
+		"contexts":         testContextsJSON,
+	})
+
+	e, err := TransformTyped(line)
+	if err != nil {
+		t.Fatalf("TransformTyped: %v", err)
+	}
+	if e.UnstructEvent == nil || e.UnstructEvent.Schema != "iglu:com.acme/click/jsonschema/1-0-0" {
+		t.Fatalf("unstruct event not parsed: %+v", e.UnstructEvent)
+	}
+	if len(e.Contexts) != 1 || e.Contexts[0].Schema != "iglu:com.acme/ad/jsonschema/1-0-0" {
+		t.Fatalf("contexts not parsed: %+v", e.Contexts)
+	}
+
+	tsv, err := e.ToTSV()
+	if err != nil {
+		t.Fatalf("ToTSV: %v", err)
+	}
+	roundTripped, err := TransformTyped(tsv)
+	if err != nil {
+		t.Fatalf("TransformTyped(ToTSV output): %v", err)
+	}
+	if roundTripped.AppID != e.AppID {
+		t.Fatalf("AppID mismatch after round trip: got %q, want %q", roundTripped.AppID, e.AppID)
+	}
+	if roundTripped.UnstructEvent == nil || roundTripped.UnstructEvent.Schema != e.UnstructEvent.Schema {
+		t.Fatalf("unstruct event lost on round trip: %+v", roundTripped.UnstructEvent)
+	}
+	if len(roundTripped.Contexts) != 1 || roundTripped.Contexts[0].Schema != e.Contexts[0].Schema {
+		t.Fatalf("contexts lost on round trip: %+v", roundTripped.Contexts)
+	}
+}
+
+func TestEventToTSVBlankCollectorTstampStaysBlank(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id": "test-app",
+	})
+
+	e, err := TransformTyped(line)
+	if err != nil {
+		t.Fatalf("TransformTyped: %v", err)
+	}
+	if !e.CollectorTstamp.IsZero() {
+		t.Fatalf("expected a blank collector_tstamp to parse as the zero value, got %v", e.CollectorTstamp)
+	}
+
+	tsv, err := e.ToTSV()
+	if err != nil {
+		t.Fatalf("ToTSV: %v", err)
+	}
+	cols := strings.Split(tsv, "\t")
+	if got := cols[indexOfField("collector_tstamp")]; got != "" {
+		t.Fatalf("expected collector_tstamp to round-trip blank, got %q", got)
+	}
+}
+
+func TestToTSVLineRoundTrip(t *testing.T) {
+	line := buildEnrichedLine(map[string]string{
+		"app_id":           "test-app",
+		"collector_tstamp": "2019-05-10 14:40:30.836",
+		"event_id":         "event-id-1",
+		"unstruct_event":   testUnstructJSON,
+		"contexts":         testContextsJSON,
+	})
+
+	event, err := Transform(line, EnrichedEventFieldTypes, false)
+	if err != nil {
+		t.Fatalf("Transform: %v", err)
+	}
+	if _, ok := event["unstruct_event_com_acme_click_1"]; !ok {
+		t.Fatalf("expected shredded unstruct key in map: %v", event)
+	}
+	if _, ok := event["contexts_com_acme_ad_1"]; !ok {
+		t.Fatalf("expected shredded contexts key in map: %v", event)
+	}
+
+	out, err := ToTSVLine(event, EnrichedEventFieldTypes)
+	if err != nil {
+		t.Fatalf("ToTSVLine: %v", err)
+	}
+	cols := strings.Split(out, "\t")
+
+	unstructCol := cols[indexOfField("unstruct_event")]
+	if unstructCol == "" {
+		t.Fatalf("unstruct_event column came back empty: %q", out)
+	}
+	if !strings.Contains(unstructCol, "com.acme/click") {
+		t.Fatalf("unstruct_event column missing shredded schema name: %q", unstructCol)
+	}
+
+	contextsCol := cols[indexOfField("contexts")]
+	if contextsCol == "" {
+		t.Fatalf("contexts column came back empty: %q", out)
+	}
+	if !strings.Contains(contextsCol, "\"adId\":\"1\"") {
+		t.Fatalf("contexts column missing inner data: %q", contextsCol)
+	}
+}
+
+func indexOfField(name string) int {
+	for i, t := range EnrichedEventFieldTypes {
+		if t[0] == name {
+			return i
+		}
+	}
+	return -1
+}